@@ -7,6 +7,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/portto/bsc-indexer/filters"
 )
 
 func NewAPIService(i *Indexer) *APIService {
@@ -20,6 +22,9 @@ type APIService struct {
 func (s APIService) ListenAndServe(addr string) {
 	r := gin.Default()
 	r.GET("/blocks", s.blocksHandler)
+	r.GET("/logs", s.logsHandler)
+	r.GET("/sync", s.syncStatsHandler)
+	r.GET("/addresses/:addr/transactions", s.addressTransactionsHandler)
 	err := r.Run(addr)
 	if err != nil {
 		log.Fatal("failed to run http server, ", err)
@@ -48,3 +53,86 @@ func (s APIService) blocksHandler(c *gin.Context) {
 		"blocks": blocks,
 	})
 }
+
+func (s APIService) logsHandler(c *gin.Context) {
+	from, err := strconv.ParseUint(c.Query("fromBlock"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "fromBlock must be a number",
+		})
+		return
+	}
+
+	to, err := strconv.ParseUint(c.Query("toBlock"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "toBlock must be a number",
+		})
+		return
+	}
+
+	var filter filters.Filter
+	filter = append(filter, addressOrWildcard(c.Query("address")))
+	for i := 0; ; i++ {
+		topic, ok := c.GetQuery(fmt.Sprintf("topic%d", i))
+		if !ok {
+			break
+		}
+		filter = append(filter, addressOrWildcard(topic))
+	}
+
+	numbers, err := s.indexer.MatchBlocks(c.Request.Context(), from, to, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": fmt.Sprintf("error: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"blocks": numbers,
+	})
+}
+
+func (s APIService) syncStatsHandler(c *gin.Context) {
+	pending, cached := s.indexer.Stats()
+
+	c.JSON(http.StatusOK, gin.H{
+		"pending": pending,
+		"cached":  cached,
+	})
+}
+
+func (s APIService) addressTransactionsHandler(c *gin.Context) {
+	addr := c.Param("addr")
+
+	limitRaw := c.DefaultQuery("limit", "10")
+	limit, err := strconv.Atoi(limitRaw)
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "limit must be a number and between 1 and 100",
+		})
+		return
+	}
+
+	txs, err := s.indexer.GetAddressTransactions(addr, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": fmt.Sprintf("error: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transactions": txs,
+	})
+}
+
+// addressOrWildcard turns a single query value into a one-element OR-group,
+// or a wildcard (nil) group when the query param was left empty.
+func addressOrWildcard(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return []string{value}
+}
@@ -0,0 +1,35 @@
+package filters
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SectionSize is how many consecutive block numbers share one bloombits
+// section. Each of a bloom's 2048 bits is stored as its own SectionSize-bit
+// column spanning a section (SectionSize/8 bytes), rather than as 2048
+// whole per-block blooms, so Matcher only needs to fetch the handful of
+// columns a filter's terms actually touch instead of every block's bloom -
+// the same layout go-ethereum's core/bloombits.Matcher uses.
+const SectionSize = 4096
+
+// BitIndexes returns the 3 bit positions (0..2047) that data (an address or
+// topic's raw bytes) sets, using the same 3-of-2048 scheme
+// types.Bloom.Add uses internally to flip a bloom's bits. Deriving positions
+// straight from the raw item, rather than reverse-scanning a finished
+// types.Bloom, keeps indexing and matching using the exact same derivation.
+func BitIndexes(data []byte) [3]uint {
+	hash := crypto.Keccak256(data)
+
+	var idx [3]uint
+	for i := range idx {
+		idx[i] = (uint(hash[2*i])<<8 | uint(hash[2*i+1])) & 2047
+	}
+
+	return idx
+}
+
+// Section returns the section number and in-section bit offset for block
+// number.
+func Section(number uint64) (section uint64, offset uint) {
+	return number / SectionSize, uint(number % SectionSize)
+}
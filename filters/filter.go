@@ -0,0 +1,79 @@
+// Package filters implements address/topic matching against per-block bloom
+// filters, so API consumers can query or subscribe to logs without scanning
+// every stored block.
+package filters
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Filter is a set of OR-groups that must all match (AND) for a log to pass:
+// Filter[0] is the address group, Filter[1:] are topic-position groups, each
+// OR'd within itself. An empty group is a wildcard. This mirrors the
+// addresses/topics convention of go-ethereum's FilterCriteria.
+type Filter [][]string
+
+// Matches checks a log's address and topics against the filter exactly.
+func (f Filter) Matches(address string, topics []string) bool {
+	for i, group := range f {
+		if len(group) == 0 {
+			continue
+		}
+
+		var hit bool
+		if i == 0 {
+			hit = containsFold(group, address)
+		} else {
+			pos := i - 1
+			if pos >= len(topics) {
+				return false
+			}
+			hit = containsFold(group, topics[pos])
+		}
+
+		if !hit {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchesBloom checks whether a block's bloom filter could contain a log
+// satisfying the filter. Bloom filters can false-positive, never
+// false-negative, so this is only a candidate test: callers still need to
+// fetch the block's logs and run Matches to confirm.
+func (f Filter) MatchesBloom(bloom types.Bloom) bool {
+	for _, group := range f {
+		if len(group) == 0 {
+			continue
+		}
+
+		var hit bool
+		for _, want := range group {
+			if bloom.Test(common.FromHex(want)) {
+				hit = true
+				break
+			}
+		}
+
+		if !hit {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsFold(group []string, value string) bool {
+	for _, want := range group {
+		if strings.EqualFold(want, value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestFilterMatches(t *testing.T) {
+	addr := "0x1111111111111111111111111111111111111111"
+	topic0 := "0x2222222222222222222222222222222222222222222222222222222222222222"
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		address string
+		topics  []string
+		want    bool
+	}{
+		{
+			name:    "empty filter matches anything",
+			filter:  Filter{},
+			address: addr,
+			topics:  []string{topic0},
+			want:    true,
+		},
+		{
+			name:    "address group wildcard",
+			filter:  Filter{nil},
+			address: addr,
+			topics:  nil,
+			want:    true,
+		},
+		{
+			name:    "address match is case-insensitive",
+			filter:  Filter{{"0x1111111111111111111111111111111111111111"}},
+			address: "0X1111111111111111111111111111111111111111",
+			want:    true,
+		},
+		{
+			name:    "address mismatch fails",
+			filter:  Filter{{"0x9999999999999999999999999999999999999999"}},
+			address: addr,
+			want:    false,
+		},
+		{
+			name:    "topic position mismatch fails",
+			filter:  Filter{nil, {topic0}},
+			address: addr,
+			topics:  []string{"0x0"},
+			want:    false,
+		},
+		{
+			name:    "missing topic position fails",
+			filter:  Filter{nil, {topic0}},
+			address: addr,
+			topics:  nil,
+			want:    false,
+		},
+		{
+			name:    "address and topic both match",
+			filter:  Filter{{addr}, {topic0}},
+			address: addr,
+			topics:  []string{topic0},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.address, tt.topics); got != tt.want {
+				t.Errorf("Matches(%q, %v) = %v, want %v", tt.address, tt.topics, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMatchesBloom(t *testing.T) {
+	addr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x9999999999999999999999999999999999999999")
+
+	var bloom types.Bloom
+	bloom.Add(addr.Bytes())
+
+	matching := Filter{{addr.Hex()}}
+	if !matching.MatchesBloom(bloom) {
+		t.Errorf("expected bloom to match address present in it")
+	}
+
+	nonMatching := Filter{{other.Hex()}}
+	if nonMatching.MatchesBloom(bloom) {
+		t.Errorf("expected bloom not to match address absent from it")
+	}
+
+	wildcard := Filter{nil}
+	if !wildcard.MatchesBloom(bloom) {
+		t.Errorf("expected wildcard group to match any bloom")
+	}
+}
@@ -0,0 +1,194 @@
+package filters
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SectionLookup returns the packed per-block bitset for bit within section
+// (SectionSize/8 bytes), or nil if that section has no bits indexed yet.
+type SectionLookup func(bit uint, section uint64) ([]byte, error)
+
+// Matcher streams the numbers of blocks in a range whose bloom bits could
+// satisfy Filter, fetching the 3 (bit, section) columns of each filter term
+// for every section in the range across a pool of worker goroutines instead
+// of reading every block's full bloom - go-ethereum's core/bloombits.Matcher
+// technique, so a scan over a long range reads 3 columns per term per section
+// rather than one bloom per block.
+type Matcher struct {
+	lookup  SectionLookup
+	filter  Filter
+	workers int
+}
+
+// NewMatcher builds a Matcher that probes sections returned by lookup using
+// workers concurrent goroutines.
+func NewMatcher(lookup SectionLookup, filter Filter, workers int) *Matcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Matcher{lookup: lookup, filter: filter, workers: workers}
+}
+
+// Match returns a channel of block numbers in [from, to] whose bloom bits
+// match every OR-group of m.filter. The channel is closed once every section
+// touching the range has been checked, or ctx is done.
+func (m *Matcher) Match(ctx context.Context, from, to uint64) (<-chan uint64, error) {
+	firstSection, _ := Section(from)
+	lastSection, _ := Section(to)
+
+	sections := make(chan uint64)
+	go func() {
+		defer close(sections)
+		for s := firstSection; s <= lastSection; s++ {
+			select {
+			case sections <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	matches := make(chan uint64)
+	var wg sync.WaitGroup
+	wg.Add(m.workers)
+	for i := 0; i < m.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for section := range sections {
+				offsets, err := m.matchSection(section)
+				if err != nil {
+					continue
+				}
+
+				for _, offset := range offsets {
+					number := section*SectionSize + uint64(offset)
+					if number < from || number > to {
+						continue
+					}
+
+					select {
+					case matches <- number:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(matches)
+	}()
+
+	return matches, nil
+}
+
+// matchSection ANDs every OR-group's combined bitset together and returns
+// the in-section offsets of every remaining set bit - every block in
+// section that could satisfy every group of m.filter.
+func (m *Matcher) matchSection(section uint64) ([]uint, error) {
+	var combined []byte
+	for _, group := range m.filter {
+		if len(group) == 0 {
+			continue
+		}
+
+		groupBits, err := m.orGroup(group, section)
+		if err != nil {
+			return nil, err
+		}
+		if groupBits == nil {
+			// nothing indexed for this group in this section
+			return nil, nil
+		}
+
+		if combined == nil {
+			combined = groupBits
+			continue
+		}
+		for i := range combined {
+			combined[i] &= groupBits[i]
+		}
+	}
+
+	if combined == nil {
+		// wildcard filter: every block offset in this section is a candidate,
+		// whether or not anything was indexed for it
+		combined = make([]byte, SectionSize/8)
+		for i := range combined {
+			combined[i] = 0xff
+		}
+	}
+
+	var offsets []uint
+	for i, b := range combined {
+		if b == 0 {
+			continue
+		}
+		for bit := 0; bit < 8; bit++ {
+			if b&(1<<uint(bit)) != 0 {
+				offsets = append(offsets, uint(i*8+bit))
+			}
+		}
+	}
+
+	return offsets, nil
+}
+
+// orGroup ORs together the bitsets of every alternative in group, so the
+// result is set for any block matching at least one alternative.
+func (m *Matcher) orGroup(group []string, section uint64) ([]byte, error) {
+	var result []byte
+	for _, want := range group {
+		termBits, err := m.term(common.FromHex(want), section)
+		if err != nil {
+			return nil, err
+		}
+		if termBits == nil {
+			continue
+		}
+
+		if result == nil {
+			result = termBits
+			continue
+		}
+		for i := range result {
+			result[i] |= termBits[i]
+		}
+	}
+
+	return result, nil
+}
+
+// term ANDs together the 3 bit columns BitIndexes(data) maps to, so the
+// result is set only for blocks where all 3 were observed - a candidate for
+// data's bloom having been present there, modulo false positives.
+func (m *Matcher) term(data []byte, section uint64) ([]byte, error) {
+	idx := BitIndexes(data)
+
+	var combined []byte
+	for _, bit := range idx {
+		bits, err := m.lookup(bit, section)
+		if err != nil {
+			return nil, err
+		}
+		if bits == nil {
+			return nil, nil
+		}
+
+		if combined == nil {
+			combined = append([]byte(nil), bits...)
+			continue
+		}
+		for i := range combined {
+			combined[i] &= bits[i]
+		}
+	}
+
+	return combined, nil
+}
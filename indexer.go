@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/portto/bsc-indexer/filters"
 )
 
 type Indexer struct {
@@ -15,14 +20,71 @@ type Indexer struct {
 	ethClient *Client
 	repo      Repository
 
+	// signer recovers transaction senders for watch-list matching. It's built
+	// from the chain's actual chain ID (fetched once in NewIndexer) rather
+	// than a fixed signer, since watch-list matching needs to recover the
+	// sender of ordinary EIP-155-signed legacy transactions too.
+	signer types.Signer
+
 	jobs          chan uint64
 	errors        chan error
-	currentLatest uint64
-
+	currentLatest atomic.Uint64
+
+	// confirmationNeeded is how many blocks behind currentLatest a block must be
+	// before ConfirmWorker will confirm it. Defaults to ConfirmationNeeded but can
+	// be widened via SetConfirmationDepth for chains with deeper/rarer reorgs.
+	confirmationNeeded uint64
+
+	subMu sync.RWMutex
+	subs  []*subscription
+
+	// writeCount and writeNanos are accessed atomically and back WriteStats.
+	writeCount uint64
+	writeNanos uint64
+
+	// fastSync enables the headers-first pipelined catch-up in FastSync,
+	// set via NewFastSyncIndexer.
+	fastSync bool
+
+	// syncPending and syncCached are accessed atomically and back Stats.
+	syncPending int64
+	syncCached  int64
+
+	// watchMode switches FastWorker from storing every transaction to only
+	// the ones touching an address in watch, toggled via SetWatchMode. It's
+	// an atomic.Bool since every FastWorker goroutine reads it concurrently.
+	watchMode atomic.Bool
+	watchMu   sync.RWMutex
+	watch     map[common.Address]struct{}
+
+	// done is closed once Shutdown starts, so addJob's goroutines know to
+	// stop trying to send instead of racing the close of jobs.
+	done         chan struct{}
+	shutdownOnce sync.Once
+	// doneMu guards closed, so a racing addJob either sees closed set and
+	// bails out, or registers with producers strictly before Shutdown calls
+	// producers.Wait() - never after, which would let Shutdown's close(jobs)
+	// race a send that snuck in post-close.
+	doneMu sync.Mutex
+	closed bool
+	// producers tracks addJob goroutines that haven't sent (or given up)
+	// yet; Shutdown waits on it before closing jobs.
+	producers sync.WaitGroup
+	// shutdownTimedOut is set if Shutdown's deadline passed before its
+	// workers finished draining, so StopWait can report it.
+	shutdownTimedOut atomic.Bool
+
+	// wg tracks every FastWorker and ConfirmWorker goroutine. Shutdown waits
+	// on it before closing errors, since ConfirmWorker sends on errors too.
 	wg *sync.WaitGroup
 }
 
 const (
+	// BSCChainID is the chain ID used to build idx.signer when the node's
+	// eth_chainId call fails, so watch-list sender recovery still works
+	// against the BSC chain this indexer targets.
+	BSCChainID = 56
+
 	ConfirmationNeeded = 10
 
 	SecondPerBlock = 3
@@ -34,6 +96,24 @@ const (
 	Interval = 10
 
 	MaxWorker = 3
+
+	// FilterWorkers is how many goroutines a filters.Matcher uses to probe
+	// bloombits sections concurrently.
+	FilterWorkers = 4
+
+	// BatchBlockLimit and BatchByteLimit bound how long FastWorker buffers
+	// staged blocks before flushing them to the repository as one atomic batch.
+	BatchBlockLimit = 20
+	BatchByteLimit  = 1 << 20 // 1MiB
+
+	// WatchBackfillBlocks is how far below the latest stored block AddAddress
+	// starts re-scanning for a newly-watched address's recent history; the
+	// scan does not skip blocks that are still unconfirmed.
+	WatchBackfillBlocks = 1000
+
+	// JobQueueFactor sizes the bounded jobs queue as MaxWorker*JobQueueFactor,
+	// replacing the old buffered-1 channel.
+	JobQueueFactor = 10
 )
 
 func NewIndexer(endpoint string, repo Repository) (*Indexer, error) {
@@ -41,18 +121,74 @@ func NewIndexer(endpoint string, repo Repository) (*Indexer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Indexer{
-		endpoint:  endpoint,
-		ethClient: c,
-		repo:      repo,
-		//todo: should we use buffered channel here?
-		jobs:   make(chan uint64, 1),
-		errors: make(chan error),
-		wg:     &sync.WaitGroup{},
-	}, nil
+
+	chainID, err := c.ChainID(context.Background())
+	if err != nil {
+		log.Printf("[NewIndexer] failed to fetch chain ID, defaulting to BSC mainnet, %v", err)
+		chainID = big.NewInt(BSCChainID)
+	}
+
+	idx := &Indexer{
+		endpoint:           endpoint,
+		ethClient:          c,
+		repo:               repo,
+		signer:             types.LatestSignerForChainID(chainID),
+		jobs:               make(chan uint64, MaxWorker*JobQueueFactor),
+		errors:             make(chan error),
+		confirmationNeeded: ConfirmationNeeded,
+		watch:              make(map[common.Address]struct{}),
+		done:               make(chan struct{}),
+		wg:                 &sync.WaitGroup{},
+	}
+
+	saved, err := repo.LoadWatchList()
+	if err != nil {
+		log.Printf("[NewIndexer] failed to load persisted watch list, %v", err)
+	}
+	for _, a := range saved {
+		idx.watch[common.HexToAddress(a)] = struct{}{}
+	}
+
+	return idx, nil
+}
+
+// SetConfirmationDepth overrides how many blocks behind the chain head a block
+// must be before it's considered confirmed. Useful on chains like BSC where
+// short reorgs are common and the default ConfirmationNeeded is too shallow.
+func (idx *Indexer) SetConfirmationDepth(depth uint64) {
+	idx.confirmationNeeded = depth
+}
+
+// NewFastSyncIndexer is like NewIndexer but enables FastSync mode: before
+// starting the normal per-block workers, Run catches a fresh/stale DB up to
+// the chain head via FastSync's headers-first pipeline instead of relying on
+// FastWorker's ~3s/block ceiling.
+func NewFastSyncIndexer(endpoint string, repo Repository) (*Indexer, error) {
+	idx, err := NewIndexer(endpoint, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.fastSync = true
+	return idx, nil
 }
 
 func (idx *Indexer) Run(ctx context.Context) {
+	if idx.fastSync {
+		// catchUpFastSync returns an error when the pipeline couldn't get
+		// started at all (e.g. it never reached the chain head) or when any
+		// header, body or receipt fetch failed along the way; either way the
+		// normal workers started below then pick up from repoLatest+1 as
+		// usual. Heights whose body or receipts failed mid-run are each re-enqueued on
+		// idx.jobs directly by assemble via idx.addJob, since by the time
+		// catchUpFastSync returns, repoLatest has already moved past them
+		// (other heights in the range assembled fine) and this fallback
+		// would never revisit them on its own.
+		if err := idx.catchUpFastSync(ctx); err != nil {
+			log.Printf("[Run] fast sync catch-up failed, falling back to normal sync, %v", err)
+		}
+	}
+
 	idx.wg.Add(MaxWorker)
 	for i := 0; i < MaxWorker; i++ {
 		go idx.FastWorker(ctx, i, idx.endpoint)
@@ -80,7 +216,7 @@ func (idx *Indexer) updateLatestNumber(ctx context.Context) {
 		idx.errors <- fmt.Errorf("failed to get latest number on chain, %v", err)
 		return
 	}
-	idx.currentLatest = latest
+	idx.currentLatest.Store(latest)
 }
 
 func (idx *Indexer) makeRoutineJobs(ctx context.Context) {
@@ -95,20 +231,21 @@ func (idx *Indexer) makeRoutineJobs(ctx context.Context) {
 		return
 	}
 
+	latest := idx.currentLatest.Load()
+
 	// for dev purpose, limit index range when repo empty
 	var from uint64
 	if repoLatest == 0 {
-		from = idx.currentLatest - IndexLimit
+		from = latest - IndexLimit
 	} else {
 		from = repoLatest + 1
 	}
 
-	log.Printf("adding new jobs to queue : from %d to %d\n", from, idx.currentLatest)
-	for i := from; i <= idx.currentLatest; i++ {
+	log.Printf("adding new jobs to queue : from %d to %d\n", from, latest)
+	for i := from; i <= latest; i++ {
 		select {
 		case <-ctx.Done():
-			close(idx.jobs)
-			log.Println("[makeRoutineJobs] stop add new job to queue, job channel closed")
+			log.Println("[makeRoutineJobs] stop adding new jobs to queue, context done")
 			return
 		default:
 			idx.addJob(i)
@@ -116,10 +253,81 @@ func (idx *Indexer) makeRoutineJobs(ctx context.Context) {
 	}
 }
 
+// addJob queues height n without blocking the caller. Unlike the previous
+// fire-and-forget goroutine, it tracks itself in idx.producers and bails out
+// once idx.closed is set, so Shutdown can safely close idx.jobs without
+// racing a goroutine still trying to send on it. The closed check and the
+// producers.Add must happen under doneMu: Shutdown sets closed and only then
+// calls producers.Wait(), so a goroutine can never register with producers
+// after Shutdown has already stopped waiting on it.
 func (idx *Indexer) addJob(n uint64) {
+	idx.doneMu.Lock()
+	if idx.closed {
+		idx.doneMu.Unlock()
+		return
+	}
+	idx.producers.Add(1)
+	idx.doneMu.Unlock()
+
 	go func() {
-		idx.jobs <- n
+		defer idx.producers.Done()
+		select {
+		case idx.jobs <- n:
+		case <-idx.done:
+		}
+	}()
+}
+
+// Shutdown stops addJob from accepting new heights, waits for any addJob
+// goroutine still trying to send before closing idx.jobs (closing it while a
+// send is in flight panics), then waits up to ctx's deadline for FastWorker
+// and ConfirmWorker to finish. Callers should cancel Run's context around the
+// same time, since that's what lets ConfirmWorker's own loop return - but
+// Run's select loop stops reading idx.errors the moment it sees ctx.Done(),
+// so a worker that hits an error in that window would block forever on
+// `idx.errors <-` with nobody left to receive. Shutdown keeps draining
+// idx.errors itself until every worker has actually exited (idx.wg reaching
+// zero), not just until idx.jobs is closed, and keeps doing so past its own
+// deadline so a timed-out caller doesn't leave a worker wedged and StopWait
+// hanging forever behind it.
+func (idx *Indexer) Shutdown(ctx context.Context) error {
+	idx.shutdownOnce.Do(func() {
+		idx.doneMu.Lock()
+		idx.closed = true
+		idx.doneMu.Unlock()
+		close(idx.done)
+	})
+
+	idx.producers.Wait()
+	close(idx.jobs)
+
+	drained := make(chan struct{})
+	go func() {
+		idx.wg.Wait()
+		close(drained)
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-drained:
+				close(idx.errors)
+				return
+			case err, ok := <-idx.errors:
+				if ok {
+					log.Printf("[Shutdown] error received while draining, %v", err)
+				}
+			}
+		}
 	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		idx.shutdownTimedOut.Store(true)
+		return fmt.Errorf("[Shutdown] timed out draining in-flight jobs, %v", ctx.Err())
+	}
 }
 
 func (idx *Indexer) FastWorker(ctx context.Context, id int, endpoint string) {
@@ -130,51 +338,273 @@ func (idx *Indexer) FastWorker(ctx context.Context, id int, endpoint string) {
 		return
 	}
 
+	batch := idx.repo.NewBatch()
+	pending := 0
+
+	flush := func() {
+		if pending == 0 {
+			return
+		}
+
+		start := time.Now()
+		if err := batch.Write(); err != nil {
+			idx.errors <- fmt.Errorf("[FastWorker] failed to flush batch, %v", err)
+		}
+		idx.recordWrite(pending, time.Since(start))
+
+		batch.Reset()
+		pending = 0
+	}
+
 	for {
 		select {
 		case number, ok := <-idx.jobs:
 			if !ok {
+				flush()
 				log.Printf("[FastWorker] jobs channel closed, stop worker %d", id)
 				return
 			}
 
-			err := idx.fetchAndStoreBlock(ctx, client, number)
+			receipts, err := idx.stageBlock(client, batch, number)
 			if err != nil {
 				idx.errors <- fmt.Errorf("[FastWorker] failed to fetch block and store, %v", err)
+				continue
+			}
+			pending++
+			idx.notifySubscribers(receipts)
+
+			if pending >= BatchBlockLimit || batch.ValueSize() >= BatchByteLimit {
+				flush()
 			}
 		case <-ctx.Done():
+			flush()
 			log.Printf("[FastWorker] receive cancel singal, stop FastWorker %d", id)
 			return
 		}
 	}
 }
 
-func (idx *Indexer) fetchAndStoreBlock(ctx context.Context, client *Client, number uint64) error {
+// stageBlock fetches a block and its receipts and adds it to batch without
+// flushing, so FastWorker can accumulate several blocks into one atomic
+// write. It returns the fetched receipts so the caller can still notify
+// Subscribe-ers as soon as the block is staged, ahead of the flush.
+func (idx *Indexer) stageBlock(client *Client, batch Batch, number uint64) ([]*types.Receipt, error) {
 	blockRaw, err := client.GetBlockByNumber(context.TODO(), number)
 	if err != nil {
-		return fmt.Errorf("[FastWorker] failed to get block, %v", err)
+		return nil, fmt.Errorf("[FastWorker] failed to get block, %v", err)
 	}
 
-	hashes := make([]string, len(blockRaw.Transactions()))
-	for i, transaction := range blockRaw.Transactions() {
-		hashes[i] = transaction.Hash().String()
+	hashes := make([]string, 0, len(blockRaw.Transactions()))
+	receipts := make([]*types.Receipt, 0, len(blockRaw.Transactions()))
+	for _, transaction := range blockRaw.Transactions() {
+		hash := transaction.Hash().String()
+
+		receipt, err := client.GetTransactionReceipt(context.TODO(), hash)
+		if err != nil {
+			log.Printf("[FastWorker] failed to get receipt for %s, its logs will be missing from the block bloom, %v", hash, err)
+		} else {
+			receipts = append(receipts, receipt)
+		}
+
+		if idx.watchMode.Load() {
+			if idx.matchesWatchList(transaction, receipt) {
+				idx.stageWatchedTransaction(batch, hash, transaction, receipt)
+				hashes = append(hashes, hash)
+			}
+			continue
+		}
+
+		hashes = append(hashes, hash)
 	}
+
+	items := blockBloomItems(blockRaw.Transactions(), receipts)
 	blockModel := &Block{
 		Number:       blockRaw.NumberU64(),
 		Hash:         blockRaw.Hash().String(),
 		Time:         blockRaw.Time(),
 		ParentHash:   blockRaw.ParentHash().String(),
 		Transactions: hashes,
+		Bloom:        newBlockBloomFromItems(items),
+	}
+
+	if err := batch.PutBlock(blockModel); err != nil {
+		return nil, fmt.Errorf("[FastWorker] failed to stage block, %v", err)
+	}
+	idx.indexBloomBits(blockModel.Number, items)
+
+	return receipts, nil
+}
+
+func (idx *Indexer) recordWrite(blocks int, d time.Duration) {
+	atomic.AddUint64(&idx.writeCount, uint64(blocks))
+	atomic.AddUint64(&idx.writeNanos, uint64(d.Nanoseconds()))
+}
+
+// WriteStats reports the cumulative number of blocks flushed to the
+// repository and the average flush latency per block, for monitoring
+// initial-sync write throughput.
+func (idx *Indexer) WriteStats() (blocks uint64, avgLatencyPerBlock time.Duration) {
+	blocks = atomic.LoadUint64(&idx.writeCount)
+	if blocks == 0 {
+		return 0, 0
+	}
+
+	nanos := atomic.LoadUint64(&idx.writeNanos)
+	return blocks, time.Duration(nanos / blocks)
+}
+
+// blockBloomItems returns the raw address/topic bytes a block's bloom is
+// built over: its transactions' to-addresses and its receipts' log
+// addresses/topics. newBlockBloom and indexBloomBits both derive from this
+// same list, so the per-block bloom and the bit-sectioned index it backs
+// never disagree about what the block touched.
+func blockBloomItems(txs []*types.Transaction, receipts []*types.Receipt) [][]byte {
+	var items [][]byte
+	for _, tx := range txs {
+		if to := tx.To(); to != nil {
+			items = append(items, to.Bytes())
+		}
+	}
+
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			items = append(items, l.Address.Bytes())
+			for _, t := range l.Topics {
+				items = append(items, t.Bytes())
+			}
+		}
+	}
+
+	return items
+}
+
+// newBlockBloom builds a bloom filter over a block's to-addresses and its
+// receipts' log addresses/topics, so filters.Filter.MatchesBloom can cheaply
+// test the block as a candidate without reading its logs.
+func newBlockBloom(txs []*types.Transaction, receipts []*types.Receipt) BlockBloom {
+	return newBlockBloomFromItems(blockBloomItems(txs, receipts))
+}
+
+// newBlockBloomFromItems is newBlockBloom's second half, split out so
+// callers that already derived items for indexBloomBits don't recompute them.
+func newBlockBloomFromItems(items [][]byte) BlockBloom {
+	var bloom types.Bloom
+	for _, item := range items {
+		bloom.Add(item)
+	}
+
+	return BlockBloom(bloom)
+}
+
+// indexBloomBits updates the bloombits sections backing filters.Matcher's
+// sub-linear scans: for each address/topic newBlockBloom folded into
+// number's bloom, it flips that item's 3 bits on at number's offset in its
+// section. A failure here only degrades /logs and Subscribe's candidate
+// scan (the per-block Bloom field and the full transaction/log records are
+// still stored fine), so it's logged rather than propagated as a staging
+// failure.
+func (idx *Indexer) indexBloomBits(number uint64, items [][]byte) {
+	seen := make(map[uint]struct{}, 3*len(items))
+	for _, item := range items {
+		for _, bit := range filters.BitIndexes(item) {
+			if _, ok := seen[bit]; ok {
+				continue
+			}
+			seen[bit] = struct{}{}
+
+			if err := idx.repo.SetBloomBit(bit, number); err != nil {
+				log.Printf("[indexBloomBits] failed to set bloom bit %d for block %d, %v", bit, number, err)
+			}
+		}
 	}
+}
+
+// subscription is a single Subscribe call's filter and delivery channel.
+type subscription struct {
+	filter filters.Filter
+	ch     chan *Log
+}
+
+// Subscribe registers filter and returns a channel of matching logs as
+// FastWorker (or FastSync) stages new blocks, ahead of their batch flush.
+// The channel is closed once ctx is done.
+func (idx *Indexer) Subscribe(ctx context.Context, filter filters.Filter) <-chan *Log {
+	sub := &subscription{filter: filter, ch: make(chan *Log, 16)}
 
-	_ = idx.repo.CreateBlock(blockModel)
+	idx.subMu.Lock()
+	idx.subs = append(idx.subs, sub)
+	idx.subMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		idx.subMu.Lock()
+		defer idx.subMu.Unlock()
+		for i, s := range idx.subs {
+			if s == sub {
+				idx.subs = append(idx.subs[:i], idx.subs[i+1:]...)
+				break
+			}
+		}
+		close(sub.ch)
+	}()
 
+	return sub.ch
+}
+
+func (idx *Indexer) notifySubscribers(receipts []*types.Receipt) {
+	idx.subMu.RLock()
+	defer idx.subMu.RUnlock()
+
+	if len(idx.subs) == 0 {
+		return
+	}
+
+	for _, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			address := l.Address.String()
+			topics := make([]string, len(l.Topics))
+			for i, t := range l.Topics {
+				topics[i] = t.String()
+			}
+
+			logModel := &Log{
+				Index:   uint64(l.Index),
+				Address: address,
+				Topics:  topics,
+				Data:    common.BytesToHash(l.Data).String(),
+			}
+
+			for _, sub := range idx.subs {
+				if !sub.filter.Matches(address, topics) {
+					continue
+				}
+
+				select {
+				case sub.ch <- logModel:
+				default:
+					log.Printf("[notifySubscribers] subscriber channel full, dropping log %s#%d", address, l.Index)
+				}
+			}
+		}
+	}
+}
+
+// MatchBlocks returns the numbers of blocks in [from, to] whose bloom filter
+// matches filter, backing the GET /logs endpoint.
+func (idx *Indexer) MatchBlocks(ctx context.Context, from, to uint64, filter filters.Filter) ([]uint64, error) {
+	matcher := filters.NewMatcher(idx.repo.GetBloomSection, filter, FilterWorkers)
+	matches, err := matcher.Match(ctx, from, to)
 	if err != nil {
-		log.Printf("[FastWorker] create block error, %v", err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	var numbers []uint64
+	for n := range matches {
+		numbers = append(numbers, n)
+	}
+
+	return numbers, nil
 }
 
 func (idx *Indexer) ConfirmWorker(ctx context.Context) {
@@ -184,11 +614,9 @@ func (idx *Indexer) ConfirmWorker(ctx context.Context) {
 		idx.errors <- fmt.Errorf("[ConfirmWorker] failed to create Client in ConfirmWorker, %v", err)
 		return
 	}
-	_ = client
-
 	for {
 		select {
-		case <-time.Tick(ConfirmationNeeded * SecondPerBlock * time.Second):
+		case <-time.Tick(time.Duration(idx.confirmationNeeded) * SecondPerBlock * time.Second):
 			blocks, err := idx.repo.GetUnconfirmedBlocks()
 			if err != nil {
 				idx.errors <- fmt.Errorf("failed to get unconfirmed blocks ConfirmWorker, %v", err)
@@ -200,17 +628,31 @@ func (idx *Indexer) ConfirmWorker(ctx context.Context) {
 				continue
 			}
 
-			to := int(idx.currentLatest - ConfirmationNeeded)
-			log.Printf("[ConfirmWorker] checking block from %d to %d\n", int(blocks[0].Number), to)
+			latest := idx.currentLatest.Load()
+			if latest < idx.confirmationNeeded {
+				// nothing's old enough to clear the configured depth yet
+				// (always true right after startup, and possible any time
+				// SetConfirmationDepth widens the depth past the chain
+				// height indexed so far); currentLatest-confirmationNeeded
+				// would underflow to a huge uint64 and bypass the depth
+				// check entirely below.
+				log.Printf("[ConfirmWorker] chain height %d hasn't cleared confirmation depth %d yet, no block to confirm\n", latest, idx.confirmationNeeded)
+				continue
+			}
+			to := latest - idx.confirmationNeeded
+			log.Printf("[ConfirmWorker] checking block from %d to %d\n", blocks[0].Number, to)
 
 			var validatedBlocks []*Block
 			for i := 0; i < len(blocks)-1; i++ {
-				if blocks[i].Number >= uint64(to) {
+				if blocks[i].Number >= to {
 					break
 				}
 
 				if blocks[i].Hash != blocks[i+1].ParentHash {
-					log.Printf("[ConfirmWorker] todo: update rest blocks from start from i+1\n")
+					log.Printf("[ConfirmWorker] reorg detected below block %d, rolling back\n", blocks[i+1].Number)
+					if err := idx.handleReorg(ctx, client, blocks, i); err != nil {
+						idx.errors <- fmt.Errorf("[ConfirmWorker] failed to handle reorg, %v", err)
+					}
 					break
 				}
 
@@ -231,9 +673,95 @@ func (idx *Indexer) ConfirmWorker(ctx context.Context) {
 	}
 }
 
-func (idx *Indexer) StopWait() {
+// findCommonAncestor walks backward from startNumber, comparing the stored
+// hash against the canonical hash at each height via storedHash/canonicalHash,
+// until the two agree (or height 0 is reached). It returns the height they
+// agree on and the stored hashes of every height above it, in descending
+// order. Pulled out of handleReorg as a pure function so the backward-walk
+// can be unit tested without a live Repository or Client.
+func findCommonAncestor(startNumber uint64, storedHash, canonicalHash func(number uint64) (string, error)) (ancestor uint64, orphaned []string, err error) {
+	number := startNumber
+	for number > 0 {
+		stored, err := storedHash(number)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to load stored block %d, %v", number, err)
+		}
+
+		canonical, err := canonicalHash(number)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to fetch canonical block %d, %v", number, err)
+		}
+
+		if stored == canonical {
+			break
+		}
+
+		orphaned = append(orphaned, stored)
+		number--
+	}
+
+	return number, orphaned, nil
+}
+
+// handleReorg walks backward from the divergence point found at blocks[divergeIdx]
+// (whose hash no longer matches blocks[divergeIdx+1].ParentHash), re-querying the
+// canonical chain until it finds the last stored block both sides still agree on.
+// Everything stored above that height belongs to the abandoned side chain: it's
+// all deleted, and the heights up to blocks[divergeIdx+1] are re-enqueued so
+// FastWorker refetches them from the canonical chain. Deleted heights above
+// that are not re-enqueued here; makeRoutineJobs reaches them again since the
+// repository's latest height drops back to the common ancestor.
+// Number is each block's primary key, so freeing it with DeleteBlocksFrom
+// (rather than flagging it some other way) is what lets re-indexing reuse it.
+func (idx *Indexer) handleReorg(ctx context.Context, client *Client, blocks []*Block, divergeIdx int) error {
+	storedHash := func(number uint64) (string, error) {
+		b, err := idx.repo.GetBlock(number)
+		if err != nil {
+			return "", err
+		}
+		return b.Hash, nil
+	}
+
+	canonicalHash := func(number uint64) (string, error) {
+		b, err := client.GetBlockByNumber(ctx, number)
+		if err != nil {
+			return "", err
+		}
+		return b.Hash().String(), nil
+	}
+
+	number, orphaned, err := findCommonAncestor(blocks[divergeIdx].Number, storedHash, canonicalHash)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[ConfirmWorker] common ancestor found at block %d, %d block(s) diverged\n", number, len(orphaned))
+
+	if err := idx.repo.DeleteBlocksFrom(number + 1); err != nil {
+		return fmt.Errorf("failed to delete blocks from %d, %v", number+1, err)
+	}
+
+	reindexTo := blocks[divergeIdx+1].Number
+	for h := number + 1; h <= reindexTo; h++ {
+		idx.addJob(h)
+	}
+	log.Printf("[ConfirmWorker] re-enqueued blocks %d to %d for re-indexing\n", number+1, reindexTo)
+
+	return nil
+}
+
+// StopWait blocks until every worker has stopped. It returns an error if
+// Shutdown hit its deadline before FastWorker finished draining, so callers
+// know the stop wasn't entirely clean.
+func (idx *Indexer) StopWait() error {
 	log.Println("waiting for everything stop...")
 	idx.wg.Wait()
+
+	if idx.shutdownTimedOut.Load() {
+		return fmt.Errorf("shutdown timed out before all jobs drained")
+	}
+
+	return nil
 }
 
 // APIs
@@ -248,7 +776,7 @@ func (idx *Indexer) GetNewBlocks(limit int) ([]*Block, error) {
 }
 
 func (idx *Indexer) GetBlock(number uint64) (*Block, error) {
-	block, err := idx.repo.FindBlock(number)
+	block, err := idx.repo.GetBlock(number)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get block from repo, %v", err)
 	}
@@ -275,9 +803,16 @@ func (idx *Indexer) GetTransaction(hash string) (*Transaction, error) {
 
 		logs := make([]Log, len(txReceipt.Logs))
 		for i, l := range txReceipt.Logs {
+			topics := make([]string, len(l.Topics))
+			for j, t := range l.Topics {
+				topics[j] = t.String()
+			}
+
 			logs[i] = Log{
-				Index: uint64(l.Index),
-				Data:  common.BytesToHash(l.Data).String(),
+				Index:   uint64(l.Index),
+				Address: l.Address.String(),
+				Topics:  topics,
+				Data:    common.BytesToHash(l.Data).String(),
 			}
 		}
 
@@ -0,0 +1,396 @@
+package portto
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"github.com/portto/bsc-indexer/filters"
+)
+
+// LevelDBStore is a Repository backend over a local LevelDB, for fast-sync
+// boxes that want LSM-tree write throughput instead of running a SQL server.
+// A PebbleStore would follow the same shape against cockroachdb/pebble.
+type LevelDBStore struct {
+	db *leveldb.DB
+
+	// bloomMu guards SetBloomBit's read-modify-write of a section's packed
+	// bitset, since several FastWorker goroutines can flip bits in the same
+	// section concurrently.
+	bloomMu sync.Mutex
+}
+
+func NewLevelDBStore(path string) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open leveldb at %s, %v", path, err)
+	}
+
+	return &LevelDBStore{db: db}, nil
+}
+
+const (
+	blockPrefix        = 'b'
+	blockHashPrefix    = 'h'
+	txPrefix           = 't'
+	bloomSectionPrefix = 'f'
+)
+
+func blockKey(number uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = blockPrefix
+	binary.BigEndian.PutUint64(key[1:], number)
+	return key
+}
+
+func blockHashKey(hash string) []byte {
+	return append([]byte{blockHashPrefix}, hash...)
+}
+
+func txKey(hash string) []byte {
+	return append([]byte{txPrefix}, hash...)
+}
+
+func bloomSectionKey(bit uint, section uint64) []byte {
+	key := make([]byte, 1+2+8)
+	key[0] = bloomSectionPrefix
+	binary.BigEndian.PutUint16(key[1:3], uint16(bit))
+	binary.BigEndian.PutUint64(key[3:], section)
+	return key
+}
+
+func (s *LevelDBStore) CreateBlock(b *Block) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block, %v", err)
+	}
+
+	numberKey := blockKey(b.Number)
+	batch := new(leveldb.Batch)
+	batch.Put(numberKey, data)
+	batch.Put(blockHashKey(b.Hash), numberKey)
+
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to put block, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) CreateTransaction(tx *Transaction) error {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction, %v", err)
+	}
+
+	if err := s.db.Put(txKey(tx.Hash), data, nil); err != nil {
+		return fmt.Errorf("failed to put transaction, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) CreateTransactions(transactions []*Transaction) error {
+	batch := new(leveldb.Batch)
+	for _, tx := range transactions {
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return fmt.Errorf("failed to marshal transaction, %v", err)
+		}
+		batch.Put(txKey(tx.Hash), data)
+	}
+
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to create tx records, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) GetBlock(number uint64) (*Block, error) {
+	data, err := s.db.Get(blockKey(number), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, leveldb.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get block, %v", err)
+	}
+
+	b := &Block{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block, %v", err)
+	}
+
+	return b, nil
+}
+
+func (s *LevelDBStore) FindTransaction(hash string) (*Transaction, error) {
+	data, err := s.db.Get(txKey(hash), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction, %v", err)
+	}
+
+	tx := &Transaction{}
+	if err := json.Unmarshal(data, tx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transaction, %v", err)
+	}
+
+	return tx, nil
+}
+
+func (s *LevelDBStore) GetLatestNumber() (uint64, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte{blockPrefix}), nil)
+	defer iter.Release()
+
+	if !iter.Last() {
+		return 0, iter.Error()
+	}
+
+	b := &Block{}
+	if err := json.Unmarshal(iter.Value(), b); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal latest block, %v", err)
+	}
+
+	return b.Number, iter.Error()
+}
+
+func (s *LevelDBStore) GetNewBlocks(limit int) ([]*Block, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte{blockPrefix}), nil)
+	defer iter.Release()
+
+	var blocks []*Block
+	for ok := iter.Last(); ok && len(blocks) < limit; ok = iter.Prev() {
+		b := &Block{}
+		if err := json.Unmarshal(iter.Value(), b); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal block, %v", err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, iter.Error()
+}
+
+func (s *LevelDBStore) GetUnconfirmedBlocks() ([]*Block, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte{blockPrefix}), nil)
+	defer iter.Release()
+
+	var blocks []*Block
+	for iter.Next() {
+		b := &Block{}
+		if err := json.Unmarshal(iter.Value(), b); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal block, %v", err)
+		}
+		if !b.Confirmed {
+			blocks = append(blocks, b)
+		}
+	}
+
+	return blocks, iter.Error()
+}
+
+func (s *LevelDBStore) ConfirmBlocks(blocks []*Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+	for _, blk := range blocks {
+		blk.Confirmed = true
+
+		data, err := json.Marshal(blk)
+		if err != nil {
+			return fmt.Errorf("failed to marshal confirmed block %d, %v", blk.Number, err)
+		}
+		batch.Put(blockKey(blk.Number), data)
+	}
+
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to confirm blocks, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) DeleteBlocksFrom(number uint64) error {
+	iterRange := &util.Range{Start: blockKey(number), Limit: append([]byte{blockPrefix + 1})}
+	iter := s.db.NewIterator(iterRange, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		b := &Block{}
+		if err := json.Unmarshal(iter.Value(), b); err != nil {
+			return fmt.Errorf("failed to unmarshal block during delete, %v", err)
+		}
+
+		batch.Delete(append([]byte{}, iter.Key()...))
+		batch.Delete(blockHashKey(b.Hash))
+		for _, hash := range b.Transactions {
+			batch.Delete(txKey(hash))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return fmt.Errorf("failed to iterate blocks to delete, %v", err)
+	}
+
+	if err := s.db.Write(batch, nil); err != nil {
+		return fmt.Errorf("failed to delete blocks from %d, %v", number, err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) SetBloomBit(bit uint, number uint64) error {
+	section, offset := filters.Section(number)
+	key := bloomSectionKey(bit, section)
+
+	s.bloomMu.Lock()
+	defer s.bloomMu.Unlock()
+
+	bits, err := s.db.Get(key, nil)
+	if err != nil {
+		if err != leveldb.ErrNotFound {
+			return fmt.Errorf("failed to get bloom section, %v", err)
+		}
+		bits = make([]byte, filters.SectionSize/8)
+	} else {
+		bits = append([]byte(nil), bits...)
+	}
+
+	bits[offset/8] |= 1 << (offset % 8)
+
+	if err := s.db.Put(key, bits, nil); err != nil {
+		return fmt.Errorf("failed to put bloom section, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) GetBloomSection(bit uint, section uint64) ([]byte, error) {
+	data, err := s.db.Get(bloomSectionKey(bit, section), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bloom section, %v", err)
+	}
+
+	return data, nil
+}
+
+// NewBatch returns a Batch that buffers puts in-memory and flushes them as a
+// single atomic leveldb.Batch write.
+func (s *LevelDBStore) NewBatch() Batch {
+	return &levelDBBatch{db: s.db}
+}
+
+type levelDBBatch struct {
+	db    *leveldb.DB
+	batch leveldb.Batch
+	size  int
+}
+
+func (b *levelDBBatch) PutBlock(blk *Block) error {
+	data, err := json.Marshal(blk)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block, %v", err)
+	}
+
+	numberKey := blockKey(blk.Number)
+	b.batch.Put(numberKey, data)
+	b.batch.Put(blockHashKey(blk.Hash), numberKey)
+	b.size += len(data)
+
+	return nil
+}
+
+func (b *levelDBBatch) PutTransaction(tx *Transaction) error {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction, %v", err)
+	}
+
+	b.batch.Put(txKey(tx.Hash), data)
+	b.size += len(data)
+
+	return nil
+}
+
+func (b *levelDBBatch) Write() error {
+	if err := b.db.Write(&b.batch, nil); err != nil {
+		return fmt.Errorf("failed to write batch, %v", err)
+	}
+
+	return nil
+}
+
+func (b *levelDBBatch) Reset() {
+	b.batch.Reset()
+	b.size = 0
+}
+
+func (b *levelDBBatch) ValueSize() int {
+	return b.size
+}
+
+// GetTransactionsByAddress scans the whole tx keyspace for matches, since
+// this store doesn't maintain a secondary from/to index. Fine at the
+// transaction volumes a watch-list indexer deals with; SQLStore should be
+// preferred once that stops being true.
+func (s *LevelDBStore) GetTransactionsByAddress(address string, limit int) ([]*Transaction, error) {
+	iter := s.db.NewIterator(util.BytesPrefix([]byte{txPrefix}), nil)
+	defer iter.Release()
+
+	var txs []*Transaction
+	for iter.Next() && len(txs) < limit {
+		tx := &Transaction{}
+		if err := json.Unmarshal(iter.Value(), tx); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transaction, %v", err)
+		}
+
+		if tx.From == address || tx.To == address {
+			txs = append(txs, tx)
+		}
+	}
+
+	return txs, iter.Error()
+}
+
+var watchListKey = []byte("w-watchlist")
+
+func (s *LevelDBStore) SaveWatchList(addresses []string) error {
+	data, err := json.Marshal(addresses)
+	if err != nil {
+		return fmt.Errorf("failed to marshal watch list, %v", err)
+	}
+
+	if err := s.db.Put(watchListKey, data, nil); err != nil {
+		return fmt.Errorf("failed to save watch list, %v", err)
+	}
+
+	return nil
+}
+
+func (s *LevelDBStore) LoadWatchList() ([]string, error) {
+	data, err := s.db.Get(watchListKey, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load watch list, %v", err)
+	}
+
+	var addresses []string
+	if err := json.Unmarshal(data, &addresses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal watch list, %v", err)
+	}
+
+	return addresses, nil
+}
@@ -4,15 +4,39 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 type Block struct {
-	Number       uint64   `json:"block_num" gorm:"primaryKey"`
-	Hash         string   `json:"block_hash"`
-	Time         uint64   `json:"block_time"`
-	ParentHash   string   `json:"parent_hash"`
-	Transactions TxHashes `json:"transactions,omitempty"`
-	Confirmed    bool     `json:"confirmed"`
+	Number       uint64     `json:"block_num" gorm:"primaryKey"`
+	Hash         string     `json:"block_hash"`
+	Time         uint64     `json:"block_time"`
+	ParentHash   string     `json:"parent_hash"`
+	Transactions TxHashes   `json:"transactions,omitempty"`
+	Confirmed    bool       `json:"confirmed"`
+	Bloom        BlockBloom `json:"-"`
+}
+
+// BlockBloom is a per-block bloom filter over the addresses and log topics
+// touched by the block's transactions, letting filters.Filter.MatchesBloom
+// test whether a block could contain a match without reading its
+// transactions/logs. filters.Matcher does not read it; it scans the
+// bloombits sections instead.
+type BlockBloom types.Bloom
+
+func (b *BlockBloom) Scan(src interface{}) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("unexpected type for %v", bytes)
+	}
+
+	copy(b[:], bytes)
+	return nil
+}
+
+func (b BlockBloom) Value() (driver.Value, error) {
+	return b[:], nil
 }
 
 type TxHashes []string
@@ -41,10 +65,29 @@ type Transaction struct {
 	BlockHash string `json:"-"`
 }
 
+// WatchedAddress persists one entry of the indexer's watch list (see
+// Indexer.WatchAddresses) so it survives restarts.
+type WatchedAddress struct {
+	Address string `json:"address" gorm:"primaryKey"`
+}
+
+// BloomSection is one bloom bit's column across a bloombits section: Bits is
+// a packed bitset with one bit per block offset within the section, set
+// wherever that block's bloom touched this bit position. See
+// filters.BitIndexes/filters.Section for how (Bit, Section) and the in-row
+// offset are derived.
+type BloomSection struct {
+	Bit     uint16 `gorm:"primaryKey"`
+	Section uint64 `gorm:"primaryKey"`
+	Bits    []byte
+}
+
 type Logs []Log
 type Log struct {
-	Index uint64 `json:"index"`
-	Data  string `json:"data"`
+	Index   uint64   `json:"index"`
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
 }
 
 func (t *Logs) Scan(src interface{}) error {
@@ -0,0 +1,76 @@
+package portto
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestFindCommonAncestor(t *testing.T) {
+	// heights 1..5 diverge above height 3: stored and canonical agree at 3,
+	// disagree at 4 and 5.
+	stored := map[uint64]string{1: "h1", 2: "h2", 3: "h3", 4: "h4-orphan", 5: "h5-orphan"}
+	canonical := map[uint64]string{1: "h1", 2: "h2", 3: "h3", 4: "h4-canon", 5: "h5-canon"}
+
+	storedHash := func(number uint64) (string, error) {
+		return stored[number], nil
+	}
+	canonicalHash := func(number uint64) (string, error) {
+		return canonical[number], nil
+	}
+
+	ancestor, orphaned, err := findCommonAncestor(5, storedHash, canonicalHash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ancestor != 3 {
+		t.Fatalf("expected ancestor 3, got %d", ancestor)
+	}
+
+	want := []string{"h5-orphan", "h4-orphan"}
+	if !reflect.DeepEqual(orphaned, want) {
+		t.Fatalf("expected orphaned %v, got %v", want, orphaned)
+	}
+}
+
+func TestFindCommonAncestorAllAgree(t *testing.T) {
+	storedHash := func(number uint64) (string, error) { return "same", nil }
+	canonicalHash := func(number uint64) (string, error) { return "same", nil }
+
+	ancestor, orphaned, err := findCommonAncestor(5, storedHash, canonicalHash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ancestor != 5 {
+		t.Fatalf("expected ancestor 5, got %d", ancestor)
+	}
+	if orphaned != nil {
+		t.Fatalf("expected no orphaned blocks, got %v", orphaned)
+	}
+}
+
+func TestFindCommonAncestorWalksToGenesis(t *testing.T) {
+	storedHash := func(number uint64) (string, error) { return "stored", nil }
+	canonicalHash := func(number uint64) (string, error) { return "canonical", nil }
+
+	ancestor, orphaned, err := findCommonAncestor(2, storedHash, canonicalHash)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ancestor != 0 {
+		t.Fatalf("expected walk to reach genesis (0), got %d", ancestor)
+	}
+	if len(orphaned) != 2 {
+		t.Fatalf("expected 2 orphaned blocks, got %d", len(orphaned))
+	}
+}
+
+func TestFindCommonAncestorPropagatesLookupError(t *testing.T) {
+	wantErr := fmt.Errorf("boom")
+	storedHash := func(number uint64) (string, error) { return "", wantErr }
+	canonicalHash := func(number uint64) (string, error) { return "canonical", nil }
+
+	if _, _, err := findCommonAncestor(1, storedHash, canonicalHash); err == nil {
+		t.Fatal("expected error to propagate")
+	}
+}
@@ -0,0 +1,57 @@
+package portto
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestShutdownConcurrentAddJobAndErrors races addJob callers and a worker
+// sending on idx.errors against Shutdown, the way FastWorker/ConfirmWorker
+// and makeRoutineJobs actually do. Run with -race: it exists to catch the
+// wedges/panics that Shutdown's design (draining idx.errors in Shutdown
+// instead of relying on Run, and gating addJob/close(idx.jobs) on producers)
+// exists to prevent.
+func TestShutdownConcurrentAddJobAndErrors(t *testing.T) {
+	idx := &Indexer{
+		jobs:   make(chan uint64, 1),
+		errors: make(chan error),
+		done:   make(chan struct{}),
+		wg:     &sync.WaitGroup{},
+	}
+
+	// worker stands in for FastWorker/ConfirmWorker: it keeps sending on
+	// idx.errors until wg.Done, same as a worker that hits an error in the
+	// window between ctx.Done() and the worker actually exiting.
+	idx.wg.Add(1)
+	workerDone := make(chan struct{})
+	go func() {
+		defer idx.wg.Done()
+		defer close(workerDone)
+		for i := 0; i < 100; i++ {
+			idx.errors <- fmt.Errorf("error %d", i)
+		}
+	}()
+
+	// addJob callers stand in for makeRoutineJobs, racing Shutdown the way
+	// Run's ticker does.
+	var producers sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		producers.Add(1)
+		go func(n uint64) {
+			defer producers.Done()
+			idx.addJob(n)
+		}(uint64(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := idx.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned error: %v", err)
+	}
+
+	producers.Wait()
+	<-workerDone
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/portto/bsc-indexer/filters"
 )
 
 type Repository interface {
@@ -14,6 +17,52 @@ type Repository interface {
 	FindTransaction(hash string) (*Transaction, error)
 	GetLatestNumber() (uint64, error)
 	GetNewBlocks(limit int) ([]*Block, error)
+
+	// GetUnconfirmedBlocks returns every stored block not yet marked confirmed,
+	// ordered by number ascending, so ConfirmWorker can walk the chain forward
+	// checking each block's hash against the next one's parent hash.
+	GetUnconfirmedBlocks() ([]*Block, error)
+	// ConfirmBlocks marks the given blocks confirmed once ConfirmWorker has
+	// validated they're still part of the canonical chain.
+	ConfirmBlocks(blocks []*Block) error
+
+	// DeleteBlocksFrom removes every stored block (and their transactions) from
+	// number upward. Used by ConfirmWorker to roll back the side chain left
+	// behind by a reorg.
+	DeleteBlocksFrom(number uint64) error
+
+	// SetBloomBit flips bit on for block number within its bloombits
+	// section, backing filters.Matcher's sub-linear range scans. See
+	// filters.BitIndexes and filters.Section.
+	SetBloomBit(bit uint, number uint64) error
+	// GetBloomSection returns the packed per-block bitset for bit within
+	// section, or nil if that section has no bits indexed yet.
+	GetBloomSection(bit uint, section uint64) ([]byte, error)
+
+	// NewBatch returns a Batch for staging several blocks/transactions and
+	// flushing them to the backend in one atomic write.
+	NewBatch() Batch
+
+	// GetTransactionsByAddress returns up to limit transactions whose from or
+	// to address matches, backing GET /addresses/:addr/transactions.
+	GetTransactionsByAddress(address string, limit int) ([]*Transaction, error)
+
+	// SaveWatchList persists the full watch-list so it survives restarts.
+	SaveWatchList(addresses []string) error
+	// LoadWatchList returns the persisted watch-list, or nil if none was saved.
+	LoadWatchList() ([]string, error)
+}
+
+// Batch buffers writes so FastWorker can accumulate several blocks before
+// flushing them atomically, instead of issuing a synchronous write per
+// block. This mirrors go-ethereum's ethdb.Batch, adapted to this repo's
+// domain-typed Repository methods rather than raw key/value pairs.
+type Batch interface {
+	PutBlock(*Block) error
+	PutTransaction(*Transaction) error
+	Write() error
+	Reset()
+	ValueSize() int
 }
 
 type SQLStore struct {
@@ -96,6 +145,91 @@ func (s SQLStore) GetBlock(number uint64) (*Block, error) {
 	return b, nil
 }
 
+func (s SQLStore) GetUnconfirmedBlocks() ([]*Block, error) {
+	var blocks []*Block
+	result := s.db.Where("confirmed = ?", false).Order("number asc").Find(&blocks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get unconfirmed blocks, %v", result.Error)
+	}
+
+	return blocks, nil
+}
+
+func (s SQLStore) ConfirmBlocks(blocks []*Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	numbers := make([]uint64, len(blocks))
+	for i, b := range blocks {
+		numbers[i] = b.Number
+	}
+
+	result := s.db.Model(&Block{}).Where("number IN ?", numbers).Update("confirmed", true)
+	if result.Error != nil {
+		return fmt.Errorf("failed to confirm blocks, %v", result.Error)
+	}
+
+	return nil
+}
+
+func (s SQLStore) DeleteBlocksFrom(number uint64) error {
+	var blocks []*Block
+	if err := s.db.Where("number >= ?", number).Find(&blocks).Error; err != nil {
+		return fmt.Errorf("failed to load blocks to delete from %d, %v", number, err)
+	}
+
+	hashes := make([]string, len(blocks))
+	for i, b := range blocks {
+		hashes[i] = b.Hash
+	}
+
+	if len(hashes) > 0 {
+		if err := s.db.Where("block_hash IN ?", hashes).Delete(&Transaction{}).Error; err != nil {
+			return fmt.Errorf("failed to delete transactions for blocks from %d, %v", number, err)
+		}
+	}
+
+	if err := s.db.Where("number >= ?", number).Delete(&Block{}).Error; err != nil {
+		return fmt.Errorf("failed to delete blocks from %d, %v", number, err)
+	}
+
+	return nil
+}
+
+func (s SQLStore) SetBloomBit(bit uint, number uint64) error {
+	section, offset := filters.Section(number)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		row := &BloomSection{}
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("bit = ? AND section = ?", bit, section).First(row)
+		if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
+			return result.Error
+		}
+		if result.Error == gorm.ErrRecordNotFound {
+			row = &BloomSection{Bit: uint16(bit), Section: section, Bits: make([]byte, filters.SectionSize/8)}
+		}
+
+		row.Bits[offset/8] |= 1 << (offset % 8)
+
+		return tx.Save(row).Error
+	})
+}
+
+func (s SQLStore) GetBloomSection(bit uint, section uint64) ([]byte, error) {
+	row := &BloomSection{}
+	result := s.db.Where("bit = ? AND section = ?", bit, section).First(row)
+	if result.Error == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get bloom section, %v", result.Error)
+	}
+
+	return row.Bits, nil
+}
+
 func (s SQLStore) GetLatestNumber() (uint64, error) {
 	b := &Block{}
 	result := s.db.Select("number").Last(b)
@@ -110,3 +244,107 @@ func (s SQLStore) GetLatestNumber() (uint64, error) {
 
 	return b.Number, nil
 }
+
+func (s SQLStore) NewBatch() Batch {
+	return &sqlBatch{db: s.db}
+}
+
+type sqlBatch struct {
+	db           *gorm.DB
+	blocks       []*Block
+	transactions []*Transaction
+	size         int
+}
+
+func (b *sqlBatch) PutBlock(blk *Block) error {
+	b.blocks = append(b.blocks, blk)
+	b.size += len(blk.Hash) + len(blk.ParentHash) + len(blk.Transactions)*66
+	return nil
+}
+
+func (b *sqlBatch) PutTransaction(tx *Transaction) error {
+	b.transactions = append(b.transactions, tx)
+	b.size += len(tx.Hash) + len(tx.From) + len(tx.To) + len(tx.Data)
+	return nil
+}
+
+func (b *sqlBatch) Write() error {
+	if len(b.blocks) == 0 && len(b.transactions) == 0 {
+		return nil
+	}
+
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if len(b.blocks) > 0 {
+			if err := tx.Create(&b.blocks).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(b.transactions) > 0 {
+			if err := tx.Create(&b.transactions).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write batch, %v", err)
+	}
+
+	return nil
+}
+
+func (b *sqlBatch) Reset() {
+	b.blocks = nil
+	b.transactions = nil
+	b.size = 0
+}
+
+func (b *sqlBatch) ValueSize() int {
+	return b.size
+}
+
+func (s SQLStore) GetTransactionsByAddress(address string, limit int) ([]*Transaction, error) {
+	var txs []*Transaction
+
+	result := s.db.Where("from_addr = ? OR to_addr = ?", address, address).Limit(limit).Find(&txs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to get transactions for address, %v", result.Error)
+	}
+
+	return txs, nil
+}
+
+func (s SQLStore) SaveWatchList(addresses []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&WatchedAddress{}).Error; err != nil {
+			return err
+		}
+
+		if len(addresses) == 0 {
+			return nil
+		}
+
+		records := make([]*WatchedAddress, len(addresses))
+		for i, a := range addresses {
+			records[i] = &WatchedAddress{Address: a}
+		}
+
+		return tx.Create(&records).Error
+	})
+}
+
+func (s SQLStore) LoadWatchList() ([]string, error) {
+	var records []*WatchedAddress
+	if err := s.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load watch list, %v", err)
+	}
+
+	addresses := make([]string, len(records))
+	for i, r := range records {
+		addresses[i] = r.Address
+	}
+
+	return addresses, nil
+}
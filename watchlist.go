@@ -0,0 +1,257 @@
+package portto
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SetWatchMode toggles whether FastWorker stores every transaction of every
+// block (the default) or only the ones touching a watched address. Use
+// WatchAddresses/AddAddress to populate the watch list.
+func (idx *Indexer) SetWatchMode(enabled bool) {
+	idx.watchMode.Store(enabled)
+}
+
+// WatchAddresses replaces the indexer's watch list and persists it so it
+// survives restarts.
+func (idx *Indexer) WatchAddresses(addresses []common.Address) error {
+	idx.watchMu.Lock()
+	idx.watch = make(map[common.Address]struct{}, len(addresses))
+	for _, a := range addresses {
+		idx.watch[a] = struct{}{}
+	}
+	idx.watchMu.Unlock()
+
+	return idx.persistWatchList()
+}
+
+// AddAddress adds address to the watch list, persists the change, and
+// backfills its recent history via backfillAddress. The backfill covers the
+// most recently stored blocks whether or not ConfirmWorker has confirmed
+// them yet.
+func (idx *Indexer) AddAddress(ctx context.Context, address common.Address) error {
+	idx.watchMu.Lock()
+	_, exists := idx.watch[address]
+	idx.watch[address] = struct{}{}
+	idx.watchMu.Unlock()
+
+	if exists {
+		return nil
+	}
+
+	if err := idx.persistWatchList(); err != nil {
+		return err
+	}
+
+	return idx.backfillAddress(ctx, address)
+}
+
+// RemoveAddress drops address from the watch list and persists the change.
+func (idx *Indexer) RemoveAddress(address common.Address) error {
+	idx.watchMu.Lock()
+	delete(idx.watch, address)
+	idx.watchMu.Unlock()
+
+	return idx.persistWatchList()
+}
+
+func (idx *Indexer) isWatched(address common.Address) bool {
+	idx.watchMu.RLock()
+	defer idx.watchMu.RUnlock()
+
+	_, ok := idx.watch[address]
+	return ok
+}
+
+func (idx *Indexer) persistWatchList() error {
+	idx.watchMu.RLock()
+	addresses := make([]string, 0, len(idx.watch))
+	for a := range idx.watch {
+		addresses = append(addresses, a.String())
+	}
+	idx.watchMu.RUnlock()
+
+	if err := idx.repo.SaveWatchList(addresses); err != nil {
+		return fmt.Errorf("failed to persist watch list, %v", err)
+	}
+
+	return nil
+}
+
+// GetAddressTransactions returns up to limit stored transactions touching
+// address, backing GET /addresses/:addr/transactions. address is normalized
+// to its checksummed form first, since that's how From/To were written by
+// stageWatchedTransaction, and a caller passing an all-lowercase or
+// all-uppercase address (the common case for most wallets/tooling) would
+// otherwise get zero results despite matching transactions existing.
+func (idx *Indexer) GetAddressTransactions(address string, limit int) ([]*Transaction, error) {
+	txs, err := idx.repo.GetTransactionsByAddress(common.HexToAddress(address).String(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions for address, %v", err)
+	}
+
+	return txs, nil
+}
+
+// matchesWatchList reports whether tx (or any of its receipt's logs) touches
+// a watched address.
+func (idx *Indexer) matchesWatchList(tx *types.Transaction, receipt *types.Receipt) bool {
+	for _, addr := range idx.participants(tx, receipt) {
+		if idx.isWatched(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// participants returns the addresses a transaction touches: its to-address,
+// its sender (recovered via idx.signer, which is built from the chain's
+// actual chain ID so EIP-155-signed legacy txs recover correctly), and any
+// log addresses from its receipt.
+func (idx *Indexer) participants(tx *types.Transaction, receipt *types.Receipt) []common.Address {
+	var addrs []common.Address
+
+	if to := tx.To(); to != nil {
+		addrs = append(addrs, *to)
+	}
+
+	if sender, err := types.Sender(idx.signer, tx); err == nil {
+		addrs = append(addrs, sender)
+	}
+
+	if receipt != nil {
+		for _, l := range receipt.Logs {
+			addrs = append(addrs, l.Address)
+		}
+	}
+
+	return addrs
+}
+
+func (idx *Indexer) addressInvolved(address common.Address, tx *types.Transaction, receipt *types.Receipt) bool {
+	for _, addr := range idx.participants(tx, receipt) {
+		if addr == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stageWatchedTransaction builds a Transaction record from a raw tx and its
+// receipt and adds it to batch, the same shape GetTransaction builds
+// on-demand for a single lookup.
+func (idx *Indexer) stageWatchedTransaction(batch Batch, hash string, tx *types.Transaction, receipt *types.Receipt) {
+	var to string
+	if t := tx.To(); t != nil {
+		to = t.String()
+	}
+
+	var from string
+	if sender, err := types.Sender(idx.signer, tx); err == nil {
+		from = sender.String()
+	}
+
+	var blockHash string
+	var logs Logs
+	if receipt != nil {
+		blockHash = receipt.BlockHash.String()
+		logs = make(Logs, len(receipt.Logs))
+		for i, l := range receipt.Logs {
+			topics := make([]string, len(l.Topics))
+			for j, t := range l.Topics {
+				topics[j] = t.String()
+			}
+
+			logs[i] = Log{
+				Index:   uint64(l.Index),
+				Address: l.Address.String(),
+				Topics:  topics,
+				Data:    common.BytesToHash(l.Data).String(),
+			}
+		}
+	}
+
+	txModel := &Transaction{
+		Hash:      hash,
+		From:      from,
+		To:        to,
+		Nonce:     tx.Nonce(),
+		Data:      common.Bytes2Hex(tx.Data()),
+		Value:     tx.Value().Uint64(),
+		Logs:      logs,
+		BlockHash: blockHash,
+	}
+
+	if err := batch.PutTransaction(txModel); err != nil {
+		log.Printf("[stageWatchedTransaction] failed to stage transaction %s, %v", hash, err)
+	}
+}
+
+// backfillAddress re-scans blocks latest-WatchBackfillBlocks through latest
+// (latest being the repository's highest stored block, confirmed or not) for
+// transactions touching address, so a newly-watched address isn't missing
+// its recent history. It does not limit the scan to confirmed blocks.
+func (idx *Indexer) backfillAddress(ctx context.Context, address common.Address) error {
+	latest, err := idx.repo.GetLatestNumber()
+	if err != nil {
+		return fmt.Errorf("failed to get latest number for backfill, %v", err)
+	}
+
+	from := uint64(0)
+	if latest > WatchBackfillBlocks {
+		from = latest - WatchBackfillBlocks
+	}
+
+	client, err := NewClient(idx.endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to create Client for backfill, %v", err)
+	}
+
+	batch := idx.repo.NewBatch()
+	pending := 0
+	for number := from; number <= latest; number++ {
+		blockRaw, err := client.GetBlockByNumber(ctx, number)
+		if err != nil {
+			return fmt.Errorf("failed to get block %d for backfill, %v", number, err)
+		}
+
+		for _, tx := range blockRaw.Transactions() {
+			hash := tx.Hash().String()
+
+			receipt, err := client.GetTransactionReceipt(ctx, hash)
+			if err != nil {
+				log.Printf("[backfillAddress] failed to get receipt for %s, %v", hash, err)
+				continue
+			}
+
+			if !idx.addressInvolved(address, tx, receipt) {
+				continue
+			}
+
+			idx.stageWatchedTransaction(batch, hash, tx, receipt)
+			pending++
+		}
+
+		if pending >= BatchBlockLimit {
+			if err := batch.Write(); err != nil {
+				return fmt.Errorf("failed to flush backfill batch, %v", err)
+			}
+			batch.Reset()
+			pending = 0
+		}
+	}
+
+	if pending > 0 {
+		if err := batch.Write(); err != nil {
+			return fmt.Errorf("failed to flush final backfill batch, %v", err)
+		}
+	}
+
+	return nil
+}